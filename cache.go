@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// cacheKey identifies a cached RRset by name, type and class.
+type cacheKey struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// cacheEntry is a single cached resource record together with the absolute
+// time it expires at ("time to die").
+type cacheEntry struct {
+	rr  dns.RR
+	ttd time.Time
+}
+
+// negativeEntry records a negative (NXDOMAIN/NODATA) answer for a cacheKey,
+// cached for the SOA MINIMUM as recommended by RFC 2308. rcode is the RCODE
+// the original response carried (dns.RcodeNameError for NXDOMAIN,
+// dns.RcodeSuccess for NODATA), so callers can reproduce it on a cache hit.
+type negativeEntry struct {
+	ttd   time.Time
+	rcode int
+}
+
+// Cache is an in-process DNS cache honouring record TTLs, with negative
+// caching for NXDOMAIN/NODATA responses and bounded size with LRU eviction.
+// It is safe for concurrent use.
+type Cache struct {
+	mutex    sync.RWMutex
+	entries  map[cacheKey][]cacheEntry
+	negative map[cacheKey]negativeEntry
+	lru      []cacheKey // most-recently-used at the end
+	maxSize  int
+}
+
+// NewCache creates a Cache that holds at most maxSize distinct
+// (name, type, class) keys, evicting the least recently used once full. A maxSize <= 0 means
+// unbounded.
+func NewCache(maxSize int) *Cache {
+	return &Cache{
+		entries:  make(map[cacheKey][]cacheEntry),
+		negative: make(map[cacheKey]negativeEntry),
+		maxSize:  maxSize,
+	}
+}
+
+// get returns the still-valid cached records for key, filtering out any
+// that have expired. A negative cache hit returns ok=true, negative=true and
+// the RCODE the original response carried.
+func (c *Cache) get(key cacheKey) (rrs []dns.RR, negative bool, rcode int, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if neg, found := c.negative[key]; found {
+		if time.Now().Before(neg.ttd) {
+			return nil, true, neg.rcode, true
+		}
+	}
+
+	entries, found := c.entries[key]
+	if !found {
+		return nil, false, 0, false
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		if now.Before(e.ttd) {
+			rrs = append(rrs, e.rr)
+		}
+	}
+	return rrs, false, 0, len(rrs) > 0
+}
+
+// touch moves key to the most-recently-used position.
+func (c *Cache) touch(key cacheKey) {
+	for i, k := range c.lru {
+		if k == key {
+			c.lru = append(c.lru[:i], c.lru[i+1:]...)
+			break
+		}
+	}
+	c.lru = append(c.lru, key)
+
+	for c.maxSize > 0 && len(c.lru) > c.maxSize {
+		oldest := c.lru[0]
+		c.lru = c.lru[1:]
+		delete(c.entries, oldest)
+		delete(c.negative, oldest)
+	}
+}
+
+// putRR caches a single resource record, keyed by its header's name/type/class.
+func (c *Cache) putRR(rr dns.RR) {
+	hdr := rr.Header()
+	key := cacheKey{Name: dns.Fqdn(hdr.Name), Type: hdr.Rrtype, Class: hdr.Class}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.negative, key)
+	c.entries[key] = append(c.entries[key], cacheEntry{
+		rr:  rr,
+		ttd: time.Now().Add(time.Duration(hdr.Ttl) * time.Second),
+	})
+	c.touch(key)
+}
+
+// putNegative records a negative answer for key with the given rcode,
+// cached for ttl seconds (the authority's SOA MINIMUM field, per RFC 2308).
+func (c *Cache) putNegative(key cacheKey, ttl uint32, rcode int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, key)
+	c.negative[key] = negativeEntry{ttd: time.Now().Add(time.Duration(ttl) * time.Second), rcode: rcode}
+	c.touch(key)
+}
+
+// populateFromMsg caches every record in the Answer, Ns and Extra sections
+// of msg.
+func (c *Cache) populateFromMsg(msg *dns.Msg) {
+	for _, rr := range msg.Answer {
+		c.putRR(rr)
+	}
+	for _, rr := range msg.Ns {
+		if soa, ok := rr.(*dns.SOA); ok && (msg.Rcode == dns.RcodeNameError || len(msg.Answer) == 0) {
+			// Negative response: cache under the original question using the
+			// SOA MINIMUM as the TTL, per RFC 2308.
+			for _, q := range msg.Question {
+				c.putNegative(cacheKey{Name: dns.Fqdn(q.Name), Type: q.Qtype, Class: q.Qclass}, soa.Minttl, msg.Rcode)
+			}
+			continue
+		}
+		c.putRR(rr)
+	}
+	for _, rr := range msg.Extra {
+		if _, ok := rr.(*dns.OPT); ok {
+			continue // pseudo-RR, not cacheable
+		}
+		c.putRR(rr)
+	}
+}
+
+// lookup resolves (name, qtype, qclass) from the cache, following CNAME
+// chains. It returns the final answer records, whether a dangling CNAME
+// (one whose target isn't cached) was found, whether the hit was a cached
+// negative (NXDOMAIN/NODATA) answer together with its original RCODE, and
+// whether anything relevant was cached at all.
+func (c *Cache) lookup(name string, qtype, qclass uint16) (rrs []dns.RR, danglingCNAME, negative bool, rcode int, ok bool) {
+	name = dns.Fqdn(name)
+
+	// A hit counts as a use: it moves the key to the most-recently-used
+	// position, unless the key was purged or flushed in the meantime.
+	markUsed := func(key cacheKey) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		_, pos := c.entries[key]
+		_, neg := c.negative[key]
+		if pos || neg {
+			c.touch(key)
+		}
+	}
+
+	for hops := 0; hops < 10; hops++ {
+		key := cacheKey{Name: name, Type: qtype, Class: qclass}
+		if found, neg, negRcode, negOK := c.get(key); negOK {
+			markUsed(key)
+			if neg {
+				return nil, false, true, negRcode, true
+			}
+			return append(rrs, found...), false, false, 0, true
+		}
+
+		cnameKey := cacheKey{Name: name, Type: dns.TypeCNAME, Class: qclass}
+		cnames, _, _, found := c.get(cnameKey)
+		if !found || len(cnames) == 0 {
+			if len(rrs) > 0 {
+				return rrs, true, false, 0, true // chain ends in a CNAME whose target isn't cached
+			}
+			return nil, false, false, 0, false
+		}
+		markUsed(cnameKey)
+
+		cname := cnames[0].(*dns.CNAME)
+		rrs = append(rrs, cname)
+		name = dns.Fqdn(cname.Target)
+	}
+
+	// Exhausted hops chasing what looks like a CNAME loop; report what we
+	// have rather than asking the caller to chase it further.
+	return rrs, false, false, 0, len(rrs) > 0
+}
+
+// Flush removes every entry from the cache.
+func (c *Cache) Flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[cacheKey][]cacheEntry)
+	c.negative = make(map[cacheKey]negativeEntry)
+	c.lru = nil
+}
+
+// Purge removes every cached entry (positive and negative) for name.
+func (c *Cache) Purge(name string) {
+	name = dns.Fqdn(name)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key := range c.entries {
+		if key.Name == name {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.negative {
+		if key.Name == name {
+			delete(c.negative, key)
+		}
+	}
+	for i := 0; i < len(c.lru); i++ {
+		if c.lru[i].Name == name {
+			c.lru = append(c.lru[:i], c.lru[i+1:]...)
+			i--
+		}
+	}
+}
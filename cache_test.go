@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheGetExpiresTTL(t *testing.T) {
+	c := NewCache(0)
+	rr := &dns.A{
+		Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+		A:   net.ParseIP("1.2.3.4"),
+	}
+	c.putRR(rr)
+
+	time.Sleep(2 * time.Millisecond)
+
+	rrs, negative, _, ok := c.get(cacheKey{Name: "example.com.", Type: dns.TypeA, Class: dns.ClassINET})
+	require.False(t, ok)
+	require.False(t, negative)
+	require.Empty(t, rrs)
+}
+
+func TestCacheNegativeHit(t *testing.T) {
+	c := NewCache(0)
+	key := cacheKey{Name: "nx.example.com.", Type: dns.TypeA, Class: dns.ClassINET}
+	c.putNegative(key, 60, dns.RcodeNameError)
+
+	rrs, negative, rcode, ok := c.get(key)
+	require.True(t, ok)
+	require.True(t, negative)
+	require.Equal(t, dns.RcodeNameError, rcode)
+	require.Nil(t, rrs)
+}
+
+func TestCacheLookupFollowsCNAMEChain(t *testing.T) {
+	c := NewCache(0)
+	c.putRR(&dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "alias.example.com.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: "real.example.com.",
+	})
+	c.putRR(&dns.A{
+		Hdr: dns.RR_Header{Name: "real.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP("5.6.7.8"),
+	})
+
+	rrs, dangling, negative, _, ok := c.lookup("alias.example.com.", dns.TypeA, dns.ClassINET)
+	require.True(t, ok)
+	require.False(t, dangling)
+	require.False(t, negative)
+	require.Len(t, rrs, 2) // the CNAME hop plus the final A record
+}
+
+func TestCacheLookupDanglingCNAME(t *testing.T) {
+	c := NewCache(0)
+	c.putRR(&dns.CNAME{
+		Hdr:    dns.RR_Header{Name: "alias.example.com.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300},
+		Target: "nowhere.example.com.",
+	})
+
+	rrs, dangling, negative, _, ok := c.lookup("alias.example.com.", dns.TypeA, dns.ClassINET)
+	require.True(t, ok)
+	require.True(t, dangling)
+	require.False(t, negative)
+	require.Len(t, rrs, 1)
+}
+
+func TestCacheLookupMiss(t *testing.T) {
+	c := NewCache(0)
+	_, dangling, negative, _, ok := c.lookup("missing.example.com.", dns.TypeA, dns.ClassINET)
+	require.False(t, ok)
+	require.False(t, dangling)
+	require.False(t, negative)
+}
+
+func TestCachePurgeRemovesAllTypesForName(t *testing.T) {
+	c := NewCache(0)
+	c.putRR(&dns.A{
+		Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP("1.2.3.4"),
+	})
+	c.putNegative(cacheKey{Name: "example.com.", Type: dns.TypeAAAA, Class: dns.ClassINET}, 60, dns.RcodeNameError)
+
+	c.Purge("example.com.")
+
+	_, _, _, ok := c.get(cacheKey{Name: "example.com.", Type: dns.TypeA, Class: dns.ClassINET})
+	require.False(t, ok)
+	_, _, _, ok = c.get(cacheKey{Name: "example.com.", Type: dns.TypeAAAA, Class: dns.ClassINET})
+	require.False(t, ok)
+}
@@ -1,22 +1,78 @@
 package dns
 
 import (
+	"context"
 	"errors"
 	"math/rand"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/miekg/dns"
+
+	"github.com/Ahmadkashif/retryabledns/hostsfile"
 )
 
 const defaultPort = "53"
 
 // Client is a DNS resolver client to resolve hostnames.
+//
+// Resolver entries may optionally carry an explicit transport scheme
+// (udp://, tcp://, tls://, https:// or quic://); entries without one are
+// treated as plain UDP for backwards compatibility. Client dials and caches
+// one Transport per resolver entry and round-robins across resolvers on
+// retry.
 type Client struct {
 	resolvers  []string
 	maxRetries int
 	rand       *rand.Rand
-	mutex      *sync.Mutex
+	randMutex  sync.Mutex // guards rand, which is not safe for concurrent use
+
+	// PinnedCerts optionally maps a "tls://" resolver's host:port to the
+	// expected SHA-256 fingerprint of its certificate, for DoT pinning.
+	PinnedCerts map[string]string
+
+	// timeout bounds a single try against a single resolver; it is passed to
+	// the underlying Transport (e.g. the miekg/dns.Client's Timeout field).
+	timeout time.Duration
+	// overallTimeout, if set, bounds the whole Resolve*Context call across
+	// every retry. Zero means no additional deadline beyond ctx's own.
+	overallTimeout time.Duration
+
+	transports     map[string]Transport
+	transportMutex sync.RWMutex
+	rrCounter      uint64
+
+	cache *Cache
+
+	// EDNS0 (RFC 6891) options applied to outgoing queries. See SetUDPSize,
+	// SetDo, SetEDNS0ClientSubnet and SetCookies.
+	udpSize         uint16
+	dnssecOK        bool
+	ecsAddress      net.IP
+	ecsSourcePrefix uint8
+	cookiesEnabled  bool
+	cookieStates    map[string]*cookieState
+	cookieMutex     sync.Mutex
+
+	// strategy and fastestFanout control resolver dispatch. See SetStrategy
+	// and SetFastestFanout.
+	strategy      Strategy
+	fastestFanout int
+	stats         *resolverStatsTracker
+
+	// hosts, if non-nil, is consulted before the network for TypeA, TypeAAAA
+	// and TypePTR queries. Set by NewWithHosts.
+	hostsPath    string
+	hosts        *hostsfile.HostsFile
+	hostsMutex   sync.RWMutex
+	hostsWatcher *fsnotify.Watcher
+
+	// tsig, if set, signs outgoing AXFR/IXFR requests. See SetTSIG.
+	tsig *TSIGAuth
 }
 
 // Result contains the results from a DNS resolution
@@ -28,17 +84,184 @@ type Result struct {
 // New creates a new dns client
 func New(baseResolvers []string, maxRetries int) *Client {
 	client := Client{
-		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
-		mutex:      &sync.Mutex{},
-		maxRetries: maxRetries,
-		resolvers:  baseResolvers,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		maxRetries:  maxRetries,
+		resolvers:   baseResolvers,
+		PinnedCerts: make(map[string]string),
+		transports:  make(map[string]Transport),
+		cache:       NewCache(0),
+		stats:       newResolverStatsTracker(),
 	}
 	return &client
 }
 
+// SetCacheMaxSize bounds the in-process cache to at most n distinct
+// (name, type, class) keys, evicting the least recently used once full. n <= 0 means
+// unbounded. Calling this discards any entries already cached.
+func (c *Client) SetCacheMaxSize(n int) {
+	c.cache = NewCache(n)
+}
+
+// SetTimeout bounds how long a single try against a single resolver may
+// take. The bound applies to every try made after the call; transports
+// created before it also keep the timeout they were built with.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// SetOverallTimeout bounds the total time a Resolve*Context call may spend
+// across all of its retries. Zero (the default) means retries are bounded
+// only by maxRetries and the caller's own context.
+func (c *Client) SetOverallTimeout(d time.Duration) {
+	c.overallTimeout = d
+}
+
+// Flush empties the in-process DNS cache.
+func (c *Client) Flush() {
+	c.cache.Flush()
+}
+
+// Purge removes every cached entry for name from the in-process DNS cache.
+func (c *Client) Purge(name string) {
+	c.cache.Purge(name)
+}
+
+// nextResolver returns the next resolver to try, round-robining across
+// c.resolvers so repeated retries spread load instead of hammering one
+// upstream.
+func (c *Client) nextResolver() string {
+	i := atomic.AddUint64(&c.rrCounter, 1)
+	return c.resolvers[i%uint64(len(c.resolvers))]
+}
+
+// exchange sends msg to resolver using the cached Transport for it,
+// constructing one on first use.
+func (c *Client) exchange(ctx context.Context, msg *dns.Msg, resolver string) (*dns.Msg, error) {
+	transport, err := c.transportFor(resolver)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Exchange(ctx, msg)
+}
+
+// withOverallDeadline applies c.overallTimeout on top of ctx, if configured,
+// so that retries are bounded even when the caller's own context has no
+// deadline.
+func (c *Client) withOverallDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.overallTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.overallTimeout)
+}
+
+// exchangeOne applies c.timeout as a per-try deadline on top of ctx before
+// handing off to the resolver's Transport.
+func (c *Client) exchangeOne(ctx context.Context, msg *dns.Msg, resolver string) (*dns.Msg, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.exchange(ctx, msg, resolver)
+}
+
+// networkQuery sends a single question for (name, qtype) to resolvers,
+// dispatching per the configured Strategy and retrying up to c.maxRetries
+// times, and caches whatever the answer contains. cacheLookup uses it to
+// chase dangling CNAMEs found in the cache. It stops early if ctx is
+// cancelled or its deadline expires.
+func (c *Client) networkQuery(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.Id = dns.Id()
+	msg.RecursionDesired = true
+	msg.Question = []dns.Question{{Name: dns.Fqdn(name), Qtype: qtype, Qclass: dns.ClassINET}}
+
+	var err error
+
+	for i := 0; i < c.maxRetries; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		var answers []resolverAnswer
+		answers, err = c.dispatch(ctx, msg)
+		if err != nil {
+			continue
+		}
+		return answers[0].msg, nil
+	}
+
+	return nil, err
+}
+
+// cacheLookup resolves (host, qtype) from the in-process cache, following
+// CNAME chains. If the chain ends in a dangling CNAME (its target isn't
+// cached), it issues one secondary network lookup for the target to
+// complete the chain before returning. negative reports whether the hit was
+// a cached NXDOMAIN/NODATA answer, in which case rcode is the RCODE the
+// original response carried; rrs is then empty, except when the negative
+// answer is for the target of a cached CNAME chain, in which case rrs holds
+// that chain.
+func (c *Client) cacheLookup(ctx context.Context, host string, qtype uint16) (rrs []dns.RR, negative bool, rcode int, ok bool) {
+	rrs, dangling, negative, rcode, ok := c.cache.lookup(host, qtype, dns.ClassINET)
+	if !ok || negative || !dangling || len(rrs) == 0 {
+		return rrs, negative, rcode, ok
+	}
+
+	tail := rrs[len(rrs)-1].(*dns.CNAME).Target
+	if _, err := c.networkQuery(ctx, tail, qtype); err != nil {
+		return rrs, false, 0, true
+	}
+
+	if resolved, _, resolvedNeg, resolvedRcode, found := c.cache.lookup(tail, qtype, dns.ClassINET); found {
+		if resolvedNeg {
+			return rrs, true, resolvedRcode, true
+		}
+		rrs = append(rrs, resolved...)
+	}
+	return rrs, false, 0, true
+}
+
 // Resolve is the underlying resolve function that actually resolves a host
 // and gets the ip records for that host.
 func (c *Client) Resolve(host string) (Result, error) {
+	return c.ResolveContext(context.Background(), host)
+}
+
+// ResolveContext is like Resolve but honours ctx for cancellation and
+// deadlines across retries, in addition to any overall timeout configured
+// with SetOverallTimeout.
+func (c *Client) ResolveContext(ctx context.Context, host string) (Result, error) {
+	ctx, cancel := c.withOverallDeadline(ctx)
+	defer cancel()
+
+	result := Result{}
+
+	if hosts, ok := c.hostsLookup(host, dns.TypeA); ok {
+		for _, rr := range hosts {
+			if t, ok := rr.(*dns.A); ok {
+				result.IPs = append(result.IPs, t.A.String())
+			}
+		}
+		return result, nil
+	}
+
+	if cached, negative, rcode, ok := c.cacheLookup(ctx, host, dns.TypeA); ok {
+		if negative {
+			if rcode == dns.RcodeSuccess {
+				return result, nil // cached NODATA
+			}
+			return result, errors.New(dns.RcodeToString[rcode])
+		}
+		for _, rr := range cached {
+			if t, ok := rr.(*dns.A); ok {
+				result.IPs = append(result.IPs, t.A.String())
+				result.TTL = int(t.Header().Ttl)
+			}
+		}
+		return result, nil
+	}
+
 	msg := new(dns.Msg)
 
 	msg.Id = dns.Id()
@@ -51,33 +274,34 @@ func (c *Client) Resolve(host string) (Result, error) {
 	}
 
 	var err error
-	var answer *dns.Msg
-
-	result := Result{}
 
 	for i := 0; i < c.maxRetries; i++ {
-		c.mutex.Lock()
-		resolver := c.resolvers[c.rand.Intn(len(c.resolvers))]
-		c.mutex.Unlock()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
 
-		answer, err = dns.Exchange(msg, resolver)
+		var answers []resolverAnswer
+		answers, err = c.dispatch(ctx, msg)
 		if err != nil {
 			continue
 		}
 
-		// In case we got some error from the server, return.
-		if answer != nil && answer.Rcode != dns.RcodeSuccess {
-			return result, errors.New(dns.RcodeToString[answer.Rcode])
-		}
-
-		for _, record := range answer.Answer {
-			// Add the IP and the TTL to the map
-			if t, ok := record.(*dns.A); ok {
-				result.IPs = append(result.IPs, t.A.String())
-				result.TTL = int(t.Header().Ttl)
+		// Every answer but the last one is only reachable under
+		// StrategyAll; merge their A records so split-horizon responses
+		// are all visible to the caller.
+		for _, a := range answers {
+			if a.msg != nil && a.msg.Rcode != dns.RcodeSuccess {
+				err = errors.New(dns.RcodeToString[a.msg.Rcode])
+				continue
+			}
+			for _, record := range a.msg.Answer {
+				if t, ok := record.(*dns.A); ok {
+					result.IPs = append(result.IPs, t.A.String())
+					result.TTL = int(t.Header().Ttl)
+				}
 			}
 		}
-		return result, nil
+		return result, err
 	}
 
 	return result, err
@@ -86,6 +310,25 @@ func (c *Client) Resolve(host string) (Result, error) {
 // ResolveRaw is the underlying resolve function that actually resolves a host
 // and gets the raw records for that host.
 func (c *Client) ResolveRaw(host string, requestType uint16) (results []string, raw string, err error) {
+	return c.ResolveRawContext(context.Background(), host, requestType)
+}
+
+// ResolveRawContext is like ResolveRaw but honours ctx for cancellation and
+// deadlines across retries.
+func (c *Client) ResolveRawContext(ctx context.Context, host string, requestType uint16) (results []string, raw string, err error) {
+	ctx, cancel := c.withOverallDeadline(ctx)
+	defer cancel()
+
+	if cached, negative, rcode, ok := c.cacheLookup(ctx, host, requestType); ok {
+		if negative {
+			if rcode == dns.RcodeSuccess {
+				return nil, "", nil // cached NODATA
+			}
+			return nil, "", errors.New(dns.RcodeToString[rcode])
+		}
+		return stringifyRRs(cached, requestType), "", nil
+	}
+
 	msg := new(dns.Msg)
 
 	msg.Id = dns.Id()
@@ -97,29 +340,32 @@ func (c *Client) ResolveRaw(host string, requestType uint16) (results []string,
 		Qclass: dns.ClassINET,
 	}
 
-	var answer *dns.Msg
-
 	for i := 0; i < c.maxRetries; i++ {
-		c.mutex.Lock()
-		resolver := c.resolvers[c.rand.Intn(len(c.resolvers))]
-		c.mutex.Unlock()
-
-		answer, err = dns.Exchange(msg, resolver)
-		if answer != nil {
-			raw = answer.String()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return results, raw, ctxErr
 		}
+
+		var answers []resolverAnswer
+		answers, err = c.dispatch(ctx, msg)
 		if err != nil {
 			continue
 		}
 
-		// In case we got some error from the server, return.
-		if answer != nil && answer.Rcode != dns.RcodeSuccess {
-			return results, raw, errors.New(dns.RcodeToString[answer.Rcode])
+		var rawParts []string
+		for _, a := range answers {
+			if a.msg == nil {
+				continue
+			}
+			rawParts = append(rawParts, a.msg.String())
+			if a.msg.Rcode != dns.RcodeSuccess {
+				err = errors.New(dns.RcodeToString[a.msg.Rcode])
+				continue
+			}
+			results = append(results, parse(a.msg, requestType)...)
 		}
+		raw = strings.Join(rawParts, "\n")
 
-		results = append(results, parse(answer, requestType)...)
-
-		return results, raw, nil
+		return results, raw, err
 	}
 
 	return results, raw, err
@@ -127,10 +373,22 @@ func (c *Client) ResolveRaw(host string, requestType uint16) (results []string,
 
 // Do sends a provided dns request and return the raw native response
 func (c *Client) Do(msg *dns.Msg) (resp *dns.Msg, err error) {
+	return c.DoContext(context.Background(), msg)
+}
+
+// DoContext is like Do but honours ctx for cancellation and deadlines
+// across retries.
+func (c *Client) DoContext(ctx context.Context, msg *dns.Msg) (resp *dns.Msg, err error) {
+	ctx, cancel := c.withOverallDeadline(ctx)
+	defer cancel()
 
 	for i := 0; i < c.maxRetries; i++ {
-		resolver := c.resolvers[rand.Intn(len(c.resolvers))]
-		resp, err = dns.Exchange(msg, resolver)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return resp, ctxErr
+		}
+
+		resolver := c.nextResolver()
+		resp, err = c.exchangeOne(ctx, msg, resolver)
 		if err != nil {
 			continue
 		}
@@ -146,12 +404,45 @@ func (c *Client) Do(msg *dns.Msg) (resp *dns.Msg, err error) {
 
 // ResolveEnrich sends a provided dns request and return enriched response
 func (c *Client) ResolveEnrich(host string, requestType uint16) (*DNSData, error) {
+	return c.ResolveEnrichContext(context.Background(), host, requestType)
+}
+
+// ResolveEnrichContext is like ResolveEnrich but honours ctx for
+// cancellation and deadlines across retries.
+func (c *Client) ResolveEnrichContext(ctx context.Context, host string, requestType uint16) (*DNSData, error) {
+	ctx, cancel := c.withOverallDeadline(ctx)
+	defer cancel()
+
 	var (
 		dnsdata DNSData
 		err     error
 		msg     dns.Msg
 	)
 
+	if hosts, ok := c.hostsLookup(host, requestType); ok {
+		dnsdata.Domain = host
+		dnsdata.Resolver = "hosts"
+		dnsdata.StatusCode = dns.RcodeToString[dns.RcodeSuccess]
+		for _, rr := range hosts {
+			dnsdata.appendRR(rr)
+		}
+		return &dnsdata, nil
+	}
+
+	if cached, negative, rcode, ok := c.cacheLookup(ctx, host, requestType); ok {
+		dnsdata.Domain = host
+		dnsdata.Resolver = "cache"
+		if negative {
+			dnsdata.StatusCode = dns.RcodeToString[rcode]
+			return &dnsdata, nil
+		}
+		dnsdata.StatusCode = dns.RcodeToString[dns.RcodeSuccess]
+		for _, rr := range cached {
+			dnsdata.appendRR(rr)
+		}
+		return &dnsdata, nil
+	}
+
 	msg.Id = dns.Id()
 	msg.RecursionDesired = true
 	msg.Question = make([]dns.Question, 1)
@@ -162,29 +453,97 @@ func (c *Client) ResolveEnrich(host string, requestType uint16) (*DNSData, error
 	}
 
 	for i := 0; i < c.maxRetries; i++ {
-		resolver := c.resolvers[rand.Intn(len(c.resolvers))]
-		var resp *dns.Msg
-		resp, err = dns.Exchange(&msg, resolver)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return &dnsdata, ctxErr
+		}
+
+		var answers []resolverAnswer
+		answers, err = c.dispatch(ctx, &msg)
 		if err != nil {
 			continue
 		}
 
-		dnsdata.Raw = resp.String()
-		dnsdata.StatusCode = dns.RcodeToString[resp.Rcode]
-		dnsdata.Resolver = resolver
+		var rawParts, resolverNames []string
+		for _, a := range answers {
+			resp := a.msg
+			if resp == nil {
+				continue
+			}
+			rawParts = append(rawParts, resp.String())
+			resolverNames = append(resolverNames, a.resolver)
+
+			dnsdata.StatusCode = dns.RcodeToString[resp.Rcode]
+			dnsdata.AD = dnsdata.AD || resp.AuthenticatedData
+			dnsdata.CD = dnsdata.CD || resp.CheckingDisabled
+			if opt := resp.IsEdns0(); opt != nil {
+				dnsdata.DO = opt.Do()
+				dnsdata.ExtendedRcode = ednsExtendedRcode(resp.Rcode, opt)
+				for _, o := range opt.Option {
+					if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+						dnsdata.ECSScope = ecs.SourceScope
+					}
+				}
+			} else {
+				dnsdata.ExtendedRcode = resp.Rcode
+			}
 
-		// In case we got some error from the server, return.
-		if resp != nil && resp.Rcode != dns.RcodeSuccess {
-			break
+			if resp.Rcode != dns.RcodeSuccess {
+				continue
+			}
+			dnsdata.ParseFromMsg(resp)
 		}
 
-		dnsdata.ParseFromMsg(resp)
+		dnsdata.Raw = strings.Join(rawParts, "\n")
+		dnsdata.Resolver = strings.Join(resolverNames, ",")
 		break
 	}
 
 	return &dnsdata, err
 }
 
+// stringifyRRs renders cached records the same way parse renders a live
+// answer, so cache hits and network hits look identical to callers.
+func stringifyRRs(rrs []dns.RR, requestType uint16) (results []string) {
+	for _, record := range rrs {
+		switch requestType {
+		case dns.TypeA:
+			if t, ok := record.(*dns.A); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypeNS:
+			if t, ok := record.(*dns.NS); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypeCNAME:
+			if t, ok := record.(*dns.CNAME); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypeSOA:
+			if t, ok := record.(*dns.SOA); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypePTR:
+			if t, ok := record.(*dns.PTR); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypeMX:
+			if t, ok := record.(*dns.MX); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypeTXT:
+			if t, ok := record.(*dns.TXT); ok {
+				results = append(results, t.String())
+			}
+		case dns.TypeAAAA:
+			if t, ok := record.(*dns.AAAA); ok {
+				results = append(results, t.String())
+			}
+		}
+	}
+
+	return
+}
+
 func parse(answer *dns.Msg, requestType uint16) (results []string) {
 	for _, record := range answer.Answer {
 		switch requestType {
@@ -240,30 +599,50 @@ type DNSData struct {
 	TXT        []string
 	Raw        string
 	StatusCode string
+
+	// AD and CD mirror the response header's Authenticated Data and
+	// Checking Disabled flags (RFC 4035).
+	AD bool
+	CD bool
+	// DO reports whether the resolver's response carried the EDNS0 DNSSEC
+	// OK bit (RFC 3225), echoing what was requested.
+	DO bool
+	// ExtendedRcode combines the message RCODE with the EDNS0 extended
+	// RCODE bits (RFC 6891 section 6.1.3); equal to StatusCode's Rcode when
+	// no OPT record was present.
+	ExtendedRcode int
+	// ECSScope is the EDNS0 Client Subnet scope prefix length the resolver
+	// returned (RFC 7871), set only when ECS was requested.
+	ECSScope uint8
 }
 
 // ParseFromMsg and enrich data
 func (d *DNSData) ParseFromMsg(msg *dns.Msg) error {
 	for _, record := range msg.Answer {
-		switch record.(type) {
-		case *dns.A:
-			d.A = append(d.A, record.String())
-		case *dns.NS:
-			d.NS = append(d.NS, record.String())
-		case *dns.CNAME:
-			d.CNAME = append(d.CNAME, record.String())
-		case *dns.SOA:
-			d.SOA = append(d.SOA, record.String())
-		case *dns.PTR:
-			d.PTR = append(d.PTR, record.String())
-		case *dns.MX:
-			d.MX = append(d.MX, record.String())
-		case *dns.TXT:
-			d.TXT = append(d.TXT, record.String())
-		case *dns.AAAA:
-			d.AAAA = append(d.AAAA, record.String())
-		}
+		d.appendRR(record)
 	}
 
 	return nil
 }
+
+// appendRR records rr into the field matching its type.
+func (d *DNSData) appendRR(rr dns.RR) {
+	switch rr.(type) {
+	case *dns.A:
+		d.A = append(d.A, rr.String())
+	case *dns.NS:
+		d.NS = append(d.NS, rr.String())
+	case *dns.CNAME:
+		d.CNAME = append(d.CNAME, rr.String())
+	case *dns.SOA:
+		d.SOA = append(d.SOA, rr.String())
+	case *dns.PTR:
+		d.PTR = append(d.PTR, rr.String())
+	case *dns.MX:
+		d.MX = append(d.MX, rr.String())
+	case *dns.TXT:
+		d.TXT = append(d.TXT, rr.String())
+	case *dns.AAAA:
+		d.AAAA = append(d.AAAA, rr.String())
+	}
+}
@@ -0,0 +1,46 @@
+package dns
+
+import (
+	"context"
+	"time"
+
+	"github.com/ameshkov/dnscrypt"
+	"github.com/ameshkov/dnsstamps"
+	"github.com/miekg/dns"
+)
+
+// schemeDNSCrypt identifies resolvers given as DNSCrypt stamps, e.g.
+// "sdns://AQMAAAAAAAAAEDE5Mi4xNjguMS4x...".
+const schemeDNSCrypt = "sdns"
+
+// dnscryptTransport implements Transport over the DNSCrypt protocol using a
+// resolver's "sdns://" stamp.
+type dnscryptTransport struct {
+	client      *dnscrypt.Client
+	stampServer *dnscrypt.ServerInfo
+	stamp       string
+}
+
+func newDNSCryptTransport(stamp string, timeout time.Duration) (*dnscryptTransport, error) {
+	parsedStamp, err := dnsstamps.NewServerStampFromString(stamp)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &dnscrypt.Client{Proto: "udp", Timeout: timeout}
+
+	info, _, err := client.DialStamp(parsedStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dnscryptTransport{client: client, stampServer: info, stamp: stamp}, nil
+}
+
+func (t *dnscryptTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := t.client.Exchange(msg, t.stampServer)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqALPN is the ALPN token for DNS-over-QUIC registered in RFC 9250.
+const doqALPN = "doq"
+
+// doqExchange dials addr over QUIC, opens a bidirectional stream and performs
+// a single length-prefixed DNS exchange as described in RFC 9250 section 4.2.
+func doqExchange(ctx context.Context, addr string, timeout time.Duration, msg *dns.Msg) (*dns.Msg, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tlsConfig := &tls.Config{NextProtos: []string{doqALPN}}
+
+	conn, err := quic.DialAddr(ctx, addr, tlsConfig, nil)
+	if err != nil {
+		return nil, fmt.Errorf("doq: dial %s: %w", addr, err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("doq: open stream: %w", err)
+	}
+	defer stream.Close()
+
+	// DoQ queries must not set the message ID (RFC 9250 section 4.2.1).
+	query := msg.Copy()
+	query.Id = 0
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeDoQMessage(stream, packed); err != nil {
+		return nil, fmt.Errorf("doq: write query: %w", err)
+	}
+	if err := stream.Close(); err != nil {
+		return nil, err
+	}
+
+	body, err := readDoQMessage(stream)
+	if err != nil {
+		return nil, fmt.Errorf("doq: read response: %w", err)
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, err
+	}
+	answer.Id = msg.Id
+	return answer, nil
+}
+
+func writeDoQMessage(w interface{ Write([]byte) (int, error) }, packed []byte) error {
+	prefix := make([]byte, 2)
+	binary.BigEndian.PutUint16(prefix, uint16(len(packed)))
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := w.Write(packed)
+	return err
+}
+
+func readDoQMessage(r interface{ Read([]byte) (int, error) }) ([]byte, error) {
+	prefix := make([]byte, 2)
+	if _, err := readFull(r, prefix); err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(prefix))
+	if _, err := readFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func readFull(r interface{ Read([]byte) (int, error) }, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,182 @@
+package dns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// cookieState remembers the client cookie we generated for a resolver and
+// the server cookie it last echoed back to us, so subsequent queries to the
+// same resolver can present both as required by RFC 7873.
+type cookieState struct {
+	client [8]byte
+	server string
+}
+
+// SetUDPSize sets the UDP payload size Client advertises to resolvers via
+// EDNS0 (RFC 6891). The default is dns.DefaultMsgSize.
+func (c *Client) SetUDPSize(size uint16) {
+	c.udpSize = size
+}
+
+// SetDo enables or disables the EDNS0 "DNSSEC OK" (DO) bit on outgoing
+// queries, asking resolvers to return DNSSEC signatures alongside answers.
+func (c *Client) SetDo(enable bool) {
+	c.dnssecOK = enable
+}
+
+// SetEDNS0ClientSubnet enables EDNS0 Client Subnet (RFC 7871) on outgoing
+// queries, sending ip truncated to sourcePrefixLength bits so resolvers can
+// return geographically appropriate answers. Pass a nil ip to disable it.
+func (c *Client) SetEDNS0ClientSubnet(ip net.IP, sourcePrefixLength uint8) {
+	c.ecsAddress = ip
+	c.ecsSourcePrefix = sourcePrefixLength
+}
+
+// SetCookies enables or disables DNS Cookies (RFC 7873): Client generates a
+// random client cookie per resolver and echoes back the server cookie it
+// was last given, mitigating off-path response spoofing.
+func (c *Client) SetCookies(enable bool) {
+	c.cookiesEnabled = enable
+	if enable && c.cookieStates == nil {
+		c.cookieStates = make(map[string]*cookieState)
+	}
+}
+
+// ednsEnabled reports whether any EDNS0 feature is configured, in which
+// case outgoing queries get an OPT pseudo-RR attached.
+func (c *Client) ednsEnabled() bool {
+	return c.udpSize != 0 || c.dnssecOK || c.ecsAddress != nil || c.cookiesEnabled
+}
+
+// applyEDNS0 attaches an OPT pseudo-RR to msg reflecting the Client's
+// configured EDNS0 options for the given resolver.
+func (c *Client) applyEDNS0(msg *dns.Msg, resolver string) {
+	udpSize := c.udpSize
+	if udpSize == 0 {
+		udpSize = dns.DefaultMsgSize
+	}
+
+	if !c.ednsEnabled() {
+		return
+	}
+
+	// Drop any OPT left by a previous try so retries against a different
+	// resolver don't accumulate stale options.
+	extra := msg.Extra[:0]
+	for _, rr := range msg.Extra {
+		if _, isOPT := rr.(*dns.OPT); !isOPT {
+			extra = append(extra, rr)
+		}
+	}
+	msg.Extra = extra
+
+	msg.SetEdns0(udpSize, c.dnssecOK)
+	opt := msg.IsEdns0()
+
+	if c.ecsAddress != nil {
+		opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+			Code:          dns.EDNS0SUBNET,
+			Family:        ecsFamily(c.ecsAddress),
+			SourceNetmask: c.ecsSourcePrefix,
+			SourceScope:   0,
+			Address:       ecsTruncate(c.ecsAddress, c.ecsSourcePrefix),
+		})
+	}
+
+	if c.cookiesEnabled {
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{
+			Code:   dns.EDNS0COOKIE,
+			Cookie: c.cookieFor(resolver),
+		})
+	}
+}
+
+// recordServerCookie stores the server cookie resp echoed back for
+// resolver, so it can be presented on the resolver's next query.
+func (c *Client) recordServerCookie(resp *dns.Msg, resolver string) {
+	if !c.cookiesEnabled || resp == nil {
+		return
+	}
+
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return
+	}
+
+	for _, o := range opt.Option {
+		cookie, ok := o.(*dns.EDNS0_COOKIE)
+		if !ok || len(cookie.Cookie) <= 16 {
+			continue
+		}
+
+		c.cookieMutex.Lock()
+		if state, found := c.cookieStates[resolver]; found {
+			state.server = cookie.Cookie[16:]
+		}
+		c.cookieMutex.Unlock()
+	}
+}
+
+// cookieFor returns the hex-encoded client+server cookie to send to
+// resolver, generating a random client cookie on first use.
+func (c *Client) cookieFor(resolver string) string {
+	c.cookieMutex.Lock()
+	defer c.cookieMutex.Unlock()
+
+	state, ok := c.cookieStates[resolver]
+	if !ok {
+		state = &cookieState{}
+		rand.Read(state.client[:])
+		c.cookieStates[resolver] = state
+	}
+
+	return hex.EncodeToString(state.client[:]) + state.server
+}
+
+func ecsFamily(ip net.IP) uint16 {
+	if ip.To4() != nil {
+		return 1
+	}
+	return 2
+}
+
+// ecsTruncate zeroes the bits of ip beyond prefixLength, so EDNS0 Client
+// Subnet never discloses host bits the caller asked to keep private. ip is
+// treated as 4 bytes (To4) if it's an IPv4 address, else as 16 bytes, to
+// match the length ecsFamily reports.
+func ecsTruncate(ip net.IP, prefixLength uint8) net.IP {
+	addr := ip.To4()
+	if addr == nil {
+		addr = ip.To16()
+	}
+
+	out := make(net.IP, len(addr))
+	copy(out, addr)
+
+	for i := range out {
+		bitOffset := i * 8
+		switch {
+		case bitOffset >= int(prefixLength):
+			out[i] = 0
+		case bitOffset+8 > int(prefixLength):
+			keepBits := int(prefixLength) - bitOffset
+			out[i] &= ^byte(0xFF >> uint(keepBits))
+		}
+	}
+
+	return out
+}
+
+// ednsExtendedRcode combines a message's base RCODE with the extended
+// RCODE bits carried in the upper byte of an OPT record's TTL field, per
+// RFC 6891 section 6.1.3.
+func ednsExtendedRcode(baseRcode int, opt *dns.OPT) int {
+	if opt == nil {
+		return baseRcode
+	}
+	return int(opt.Hdr.Ttl>>24)<<4 | baseRcode
+}
@@ -0,0 +1,38 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEDNSExtendedRcodeNoOPT(t *testing.T) {
+	require.Equal(t, dns.RcodeServerFailure, ednsExtendedRcode(dns.RcodeServerFailure, nil))
+}
+
+func TestEDNSExtendedRcodeCombinesUpperBits(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetEdns0(4096, false)
+	opt := msg.IsEdns0()
+	opt.SetExtendedRcode(dns.RcodeBadVers) // 16, needs the extended (upper) bits
+
+	got := ednsExtendedRcode(msg.Rcode, opt)
+	require.Equal(t, dns.RcodeBadVers, got)
+}
+
+func TestApplyEDNS0RebuildsOptionsPerResolver(t *testing.T) {
+	c := New([]string{"1.1.1.1:53"}, 1)
+	c.SetCookies(true)
+
+	msg := new(dns.Msg)
+	c.applyEDNS0(msg, "1.1.1.1:53")
+	opt := msg.IsEdns0()
+	require.NotNil(t, opt)
+	require.Len(t, opt.Option, 1)
+
+	// Re-applying for a different resolver must not accumulate stale options.
+	c.applyEDNS0(msg, "8.8.8.8:53")
+	opt = msg.IsEdns0()
+	require.Len(t, opt.Option, 1)
+}
@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"net"
+	"runtime"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/miekg/dns"
+
+	"github.com/Ahmadkashif/retryabledns/hostsfile"
+)
+
+// defaultHostsPath returns the platform's standard hosts file location.
+func defaultHostsPath() string {
+	if runtime.GOOS == "windows" {
+		return `C:\Windows\System32\drivers\etc\hosts`
+	}
+	return "/etc/hosts"
+}
+
+// NewWithHosts is like New but additionally consults the hosts file at
+// hostsPath (or the platform default, if empty) before going to the
+// network for TypeA, TypeAAAA and TypePTR queries. The file is parsed once
+// here and re-parsed on every write fsnotify reports, so edits take effect
+// without restarting the process.
+func NewWithHosts(baseResolvers []string, hostsPath string, maxRetries int) (*Client, error) {
+	client := New(baseResolvers, maxRetries)
+
+	if hostsPath == "" {
+		hostsPath = defaultHostsPath()
+	}
+	client.hostsPath = hostsPath
+
+	if err := client.reloadHosts(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(hostsPath); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	client.hostsWatcher = watcher
+
+	go client.watchHosts()
+
+	return client, nil
+}
+
+// reloadHosts re-parses c.hostsPath and swaps it in atomically.
+func (c *Client) reloadHosts() error {
+	h, err := hostsfile.Parse(c.hostsPath)
+	if err != nil {
+		return err
+	}
+
+	c.hostsMutex.Lock()
+	c.hosts = h
+	c.hostsMutex.Unlock()
+	return nil
+}
+
+// watchHosts reloads the hosts file whenever fsnotify reports it changed,
+// until the watcher is closed.
+func (c *Client) watchHosts() {
+	for {
+		select {
+		case event, ok := <-c.hostsWatcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				_ = c.reloadHosts()
+			}
+		case _, ok := <-c.hostsWatcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// hostsLookup consults the parsed hosts file for (host, qtype), returning
+// synthesized DNS records on a hit. Only TypeA, TypeAAAA and TypePTR are
+// served from hosts; everything else always goes to the network.
+func (c *Client) hostsLookup(host string, qtype uint16) (rrs []dns.RR, ok bool) {
+	c.hostsMutex.RLock()
+	h := c.hosts
+	c.hostsMutex.RUnlock()
+
+	if h == nil {
+		return nil, false
+	}
+
+	switch qtype {
+	case dns.TypeA:
+		for _, ip := range h.Lookup(host) {
+			if ip4 := ip.To4(); ip4 != nil {
+				rrs = append(rrs, &dns.A{
+					Hdr: dns.RR_Header{Name: dns.Fqdn(host), Rrtype: dns.TypeA, Class: dns.ClassINET},
+					A:   ip4,
+				})
+			}
+		}
+	case dns.TypeAAAA:
+		for _, ip := range h.Lookup(host) {
+			if ip.To4() == nil {
+				rrs = append(rrs, &dns.AAAA{
+					Hdr:  dns.RR_Header{Name: dns.Fqdn(host), Rrtype: dns.TypeAAAA, Class: dns.ClassINET},
+					AAAA: ip,
+				})
+			}
+		}
+	case dns.TypePTR:
+		addr := ptrToIP(host)
+		if addr == nil {
+			return nil, false
+		}
+		for _, name := range h.ReverseLookup(addr) {
+			rrs = append(rrs, &dns.PTR{
+				Hdr: dns.RR_Header{Name: dns.Fqdn(host), Rrtype: dns.TypePTR, Class: dns.ClassINET},
+				Ptr: dns.Fqdn(name),
+			})
+		}
+	default:
+		return nil, false
+	}
+
+	return rrs, len(rrs) > 0
+}
+
+// ptrToIP recovers the address a reverse-lookup question name (e.g.
+// "1.0.0.127.in-addr.arpa." or the equivalent ip6.arpa name) refers to, or
+// nil if name isn't a well-formed reverse name.
+func ptrToIP(name string) net.IP {
+	name = strings.TrimSuffix(strings.ToLower(dns.Fqdn(name)), ".")
+
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		if len(labels) != 4 {
+			return nil
+		}
+		reverseStrings(labels)
+		return net.ParseIP(strings.Join(labels, "."))
+
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		nibbles := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+		if len(nibbles) != 32 {
+			return nil
+		}
+		reverseStrings(nibbles)
+
+		hex := strings.Join(nibbles, "")
+		var groups []string
+		for i := 0; i < len(hex); i += 4 {
+			groups = append(groups, hex[i:i+4])
+		}
+		return net.ParseIP(strings.Join(groups, ":"))
+
+	default:
+		return nil
+	}
+}
+
+func reverseStrings(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
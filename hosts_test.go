@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Ahmadkashif/retryabledns/hostsfile"
+)
+
+func TestPtrToIPv4(t *testing.T) {
+	ip := ptrToIP("4.3.2.1.in-addr.arpa.")
+	require.NotNil(t, ip)
+	require.Equal(t, net.ParseIP("1.2.3.4").String(), ip.String())
+}
+
+func TestPtrToIPv6(t *testing.T) {
+	arpa, err := dns.ReverseAddr("1::1")
+	require.NoError(t, err)
+
+	ip := ptrToIP(arpa)
+	require.NotNil(t, ip)
+	require.Equal(t, net.ParseIP("1::1").String(), ip.String())
+}
+
+func TestPtrToIPInvalid(t *testing.T) {
+	require.Nil(t, ptrToIP("not-a-reverse-name.example.com."))
+}
+
+func TestHostsLookupForwardAndReverse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte("127.0.0.1 router.lan\n"), 0o644))
+
+	h, err := hostsfile.Parse(path)
+	require.NoError(t, err)
+
+	c := New([]string{"1.1.1.1:53"}, 1)
+	c.hosts = h
+
+	rrs, ok := c.hostsLookup("router.lan", dns.TypeA)
+	require.True(t, ok)
+	require.Len(t, rrs, 1)
+
+	rrs, ok = c.hostsLookup("1.0.0.127.in-addr.arpa.", dns.TypePTR)
+	require.True(t, ok)
+	require.Len(t, rrs, 1)
+}
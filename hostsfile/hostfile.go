@@ -0,0 +1,82 @@
+// Package hostsfile parses /etc/hosts-style files and answers forward and
+// reverse lookups against their contents.
+package hostsfile
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Entry is a single non-comment line of a hosts file: one address and the
+// one or more hostnames aliased to it.
+type Entry struct {
+	Addr  net.IP
+	Names []string
+}
+
+// HostsFile is the parsed, queryable contents of a hosts file.
+type HostsFile struct {
+	Entries []Entry
+
+	byName map[string][]net.IP
+	byAddr map[string][]string
+}
+
+// Parse reads and parses the hosts file at path.
+func Parse(path string) (*HostsFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &HostsFile{
+		byName: make(map[string][]net.IP),
+		byAddr: make(map[string][]string),
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		addr := net.ParseIP(fields[0])
+		if addr == nil {
+			continue
+		}
+
+		entry := Entry{Addr: addr, Names: fields[1:]}
+		h.Entries = append(h.Entries, entry)
+
+		for _, name := range entry.Names {
+			key := strings.ToLower(name)
+			h.byName[key] = append(h.byName[key], addr)
+			h.byAddr[addr.String()] = append(h.byAddr[addr.String()], name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not parse hosts file %q: %w", path, err)
+	}
+
+	return h, nil
+}
+
+// Lookup returns the addresses, if any, that name resolves to.
+func (h *HostsFile) Lookup(name string) []net.IP {
+	return h.byName[strings.ToLower(strings.TrimSuffix(name, "."))]
+}
+
+// ReverseLookup returns the hostnames, if any, aliased to addr.
+func (h *HostsFile) ReverseLookup(addr net.IP) []string {
+	return h.byAddr[addr.String()]
+}
@@ -0,0 +1,65 @@
+package dns
+
+import (
+	"sync"
+	"time"
+)
+
+// ResolverStats summarizes the observed health of a single resolver.
+type ResolverStats struct {
+	Successes   int
+	Errors      int
+	LastLatency time.Duration
+}
+
+// resolverStatsTracker accumulates ResolverStats per resolver, guarded by
+// its own mutex so it can be updated from the concurrent Strategy dispatch
+// paths.
+type resolverStatsTracker struct {
+	mutex sync.RWMutex
+	byKey map[string]*ResolverStats
+}
+
+func newResolverStatsTracker() *resolverStatsTracker {
+	return &resolverStatsTracker{byKey: make(map[string]*ResolverStats)}
+}
+
+func (t *resolverStatsTracker) record(resolver string, latency time.Duration, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.byKey[resolver]
+	if !ok {
+		s = &ResolverStats{}
+		t.byKey[resolver] = s
+	}
+
+	if err != nil {
+		s.Errors++
+		return
+	}
+	s.Successes++
+	s.LastLatency = latency
+}
+
+func (t *resolverStatsTracker) snapshot() map[string]ResolverStats {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	out := make(map[string]ResolverStats, len(t.byKey))
+	for k, v := range t.byKey {
+		out[k] = *v
+	}
+	return out
+}
+
+// recordStat records the outcome of one try against resolver.
+func (c *Client) recordStat(resolver string, latency time.Duration, err error) {
+	c.stats.record(resolver, latency, err)
+}
+
+// Stats returns a snapshot of per-resolver success/error counts and last
+// observed latency, so callers can tell which upstreams are healthy.
+func (c *Client) Stats() map[string]ResolverStats {
+	return c.stats.snapshot()
+}
@@ -0,0 +1,166 @@
+package dns
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Strategy controls how Client spreads queries across its configured
+// resolvers.
+type Strategy int
+
+const (
+	// StrategyRoundRobin cycles through resolvers in order across retries.
+	// This is the default.
+	StrategyRoundRobin Strategy = iota
+	// StrategyRandom picks a resolver at random on every try.
+	StrategyRandom
+	// StrategyFastest fans a query out to the number of resolvers set with
+	// SetFastestFanout at once
+	// and uses whichever answers first, cancelling the rest.
+	StrategyFastest
+	// StrategyAll queries every configured resolver and returns every
+	// answer received, letting callers merge record sets (e.g. to spot
+	// split-horizon DNS).
+	StrategyAll
+)
+
+// SetStrategy changes how Client dispatches queries across resolvers.
+func (c *Client) SetStrategy(s Strategy) {
+	c.strategy = s
+}
+
+// SetFastestFanout sets how many resolvers StrategyFastest queries
+// concurrently. n <= 0 means fan out to every configured resolver.
+func (c *Client) SetFastestFanout(n int) {
+	c.fastestFanout = n
+}
+
+// randomResolver returns a uniformly random resolver from c.resolvers.
+func (c *Client) randomResolver() string {
+	c.randMutex.Lock()
+	i := c.rand.Intn(len(c.resolvers))
+	c.randMutex.Unlock()
+	return c.resolvers[i]
+}
+
+// resolverAnswer pairs a resolver with the answer it returned.
+type resolverAnswer struct {
+	resolver string
+	msg      *dns.Msg
+}
+
+// dispatch sends msg for a single attempt according to the configured
+// Strategy, applying EDNS0, recording per-resolver stats and populating
+// the cache from every answer seen. It returns every resolver's answer
+// that succeeded (under StrategyFastest, only the first one); err is
+// non-nil only when every consulted resolver failed.
+func (c *Client) dispatch(ctx context.Context, msg *dns.Msg) ([]resolverAnswer, error) {
+	switch c.strategy {
+	case StrategyAll:
+		return c.dispatchAll(ctx, msg)
+	case StrategyFastest:
+		return c.dispatchFastest(ctx, msg)
+	case StrategyRandom:
+		return c.dispatchOne(ctx, msg, c.randomResolver())
+	default: // StrategyRoundRobin
+		return c.dispatchOne(ctx, msg, c.nextResolver())
+	}
+}
+
+func (c *Client) dispatchOne(ctx context.Context, msg *dns.Msg, resolver string) ([]resolverAnswer, error) {
+	answer, err := c.exchangeTracked(ctx, msg, resolver)
+	if err != nil {
+		return nil, err
+	}
+	return []resolverAnswer{{resolver: resolver, msg: answer}}, nil
+}
+
+type dispatchResult struct {
+	answer resolverAnswer
+	err    error
+}
+
+// dispatchFastest fans msg out to c.fastestFanout resolvers (or all of them)
+// concurrently and returns the first successful answer, cancelling the
+// remaining in-flight queries.
+func (c *Client) dispatchFastest(ctx context.Context, msg *dns.Msg) ([]resolverAnswer, error) {
+	n := c.fastestFanout
+	if n <= 0 || n > len(c.resolvers) {
+		n = len(c.resolvers)
+	}
+
+	fanoutCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dispatchResult, n)
+	for i := 0; i < n; i++ {
+		resolver := c.nextResolver()
+		go func(resolver string) {
+			answer, err := c.exchangeTracked(fanoutCtx, msg.Copy(), resolver)
+			results <- dispatchResult{resolverAnswer{resolver, answer}, err}
+		}(resolver)
+	}
+
+	var firstErr error
+	for i := 0; i < n; i++ {
+		r := <-results
+		if r.err == nil {
+			return []resolverAnswer{r.answer}, nil
+		}
+		if firstErr == nil {
+			firstErr = r.err
+		}
+	}
+	return nil, firstErr
+}
+
+// dispatchAll queries every configured resolver concurrently and returns
+// every answer that succeeded.
+func (c *Client) dispatchAll(ctx context.Context, msg *dns.Msg) ([]resolverAnswer, error) {
+	results := make(chan dispatchResult, len(c.resolvers))
+	for _, resolver := range c.resolvers {
+		go func(resolver string) {
+			answer, err := c.exchangeTracked(ctx, msg.Copy(), resolver)
+			results <- dispatchResult{resolverAnswer{resolver, answer}, err}
+		}(resolver)
+	}
+
+	var answers []resolverAnswer
+	var firstErr error
+	for i := 0; i < len(c.resolvers); i++ {
+		r := <-results
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		answers = append(answers, r.answer)
+	}
+
+	if len(answers) == 0 {
+		return nil, firstErr
+	}
+	return answers, nil
+}
+
+// exchangeTracked performs one EDNS0-aware exchange against resolver,
+// recording its latency/outcome in Stats and, on success, echoing its DNS
+// Cookie and populating the cache.
+func (c *Client) exchangeTracked(ctx context.Context, msg *dns.Msg, resolver string) (*dns.Msg, error) {
+	c.applyEDNS0(msg, resolver)
+
+	start := time.Now()
+	answer, err := c.exchangeOne(ctx, msg, resolver)
+	c.recordStat(resolver, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	c.recordServerCookie(answer, resolver)
+	c.cache.populateFromMsg(answer)
+	return answer, nil
+}
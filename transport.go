@@ -0,0 +1,285 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// transport scheme identifiers accepted in resolver strings, e.g.
+// "udp://1.1.1.1:53", "tls://1.1.1.1:853" or "https://cloudflare-dns.com/dns-query".
+const (
+	schemeUDP   = "udp"
+	schemeTCP   = "tcp"
+	schemeTLS   = "tls"
+	schemeHTTPS = "https"
+	schemeQUIC  = "quic"
+)
+
+// Transport abstracts the wire-level mechanism used to exchange a DNS
+// message with a single upstream resolver. Implementations exist for plain
+// UDP/TCP, DNS-over-TLS, DNS-over-HTTPS and DNS-over-QUIC.
+type Transport interface {
+	// Exchange sends msg to the upstream resolver and returns its response.
+	Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+}
+
+// parsedResolver is the outcome of parsing a resolver entry.
+type parsedResolver struct {
+	scheme string
+	addr   string // host:port for udp/tcp/tls/quic, full URL for https
+}
+
+// parseResolver splits a resolver string into its transport scheme and
+// address. Resolvers without an explicit scheme (e.g. "1.1.1.1:53" or
+// "1.1.1.1") are treated as plain UDP so existing callers keep working.
+func parseResolver(resolver string) (parsedResolver, error) {
+	if !strings.Contains(resolver, "://") {
+		return parsedResolver{scheme: schemeUDP, addr: withDefaultPort(resolver, defaultPort)}, nil
+	}
+
+	u, err := url.Parse(resolver)
+	if err != nil {
+		return parsedResolver{}, fmt.Errorf("could not parse resolver %q: %w", resolver, err)
+	}
+
+	switch u.Scheme {
+	case schemeUDP, schemeTCP:
+		return parsedResolver{scheme: u.Scheme, addr: withDefaultPort(u.Host, defaultPort)}, nil
+	case schemeTLS, schemeQUIC:
+		return parsedResolver{scheme: u.Scheme, addr: withDefaultPort(u.Host, "853")}, nil
+	case schemeHTTPS:
+		return parsedResolver{scheme: u.Scheme, addr: resolver}, nil
+	case schemeDNSCrypt:
+		return parsedResolver{scheme: u.Scheme, addr: resolver}, nil
+	default:
+		return parsedResolver{}, fmt.Errorf("unsupported resolver scheme %q", u.Scheme)
+	}
+}
+
+func withDefaultPort(hostport, port string) string {
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		return net.JoinHostPort(hostport, port)
+	}
+	return hostport
+}
+
+// newTransport builds the Transport implementation for a parsed resolver.
+func (c *Client) newTransport(pr parsedResolver) (Transport, error) {
+	switch pr.scheme {
+	case schemeUDP:
+		return &dnsTransport{client: &dns.Client{Net: "udp", Timeout: c.timeout}, addr: pr.addr}, nil
+	case schemeTCP:
+		return &dnsTransport{client: &dns.Client{Net: "tcp", Timeout: c.timeout}, addr: pr.addr}, nil
+	case schemeTLS:
+		return newDoTTransport(pr.addr, c.timeout, c.PinnedCerts[pr.addr]), nil
+	case schemeHTTPS:
+		return newDoHTransport(pr.addr, c.timeout), nil
+	case schemeQUIC:
+		return newDoQTransport(pr.addr, c.timeout), nil
+	case schemeDNSCrypt:
+		return newDNSCryptTransport(pr.addr, c.timeout)
+	default:
+		return nil, fmt.Errorf("unsupported resolver scheme %q", pr.scheme)
+	}
+}
+
+// transportFor returns the cached Transport for resolver, creating and
+// caching it on first use.
+func (c *Client) transportFor(resolver string) (Transport, error) {
+	c.transportMutex.RLock()
+	t, ok := c.transports[resolver]
+	c.transportMutex.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	pr, err := parseResolver(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err = c.newTransport(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.transportMutex.Lock()
+	c.transports[resolver] = t
+	c.transportMutex.Unlock()
+
+	return t, nil
+}
+
+// dnsTransport implements Transport over plain UDP or TCP using miekg/dns.
+type dnsTransport struct {
+	client *dns.Client
+	addr   string
+}
+
+func (t *dnsTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := t.client.ExchangeContext(ctx, msg, t.addr)
+	return resp, err
+}
+
+// dotTransport implements DNS-over-TLS (RFC 7858).
+type dotTransport struct {
+	client *dns.Client
+	addr   string
+}
+
+// newDoTTransport builds a DoT transport. If pinnedSHA256 is non-empty, the
+// server certificate's SHA-256 fingerprint is verified against it instead
+// of the usual chain validation.
+func newDoTTransport(addr string, timeout time.Duration, pinnedSHA256 string) *dotTransport {
+	tlsConfig := &tls.Config{}
+	if pinnedSHA256 != "" {
+		tlsConfig.InsecureSkipVerify = true // chain validation is replaced by the pin check below
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPin(rawCerts, pinnedSHA256)
+		}
+	}
+
+	return &dotTransport{
+		client: &dns.Client{Net: "tcp-tls", TLSConfig: tlsConfig, Timeout: timeout},
+		addr:   addr,
+	}
+}
+
+func (t *dotTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	resp, _, err := t.client.ExchangeContext(ctx, msg, t.addr)
+	return resp, err
+}
+
+func verifyPin(rawCerts [][]byte, pinnedSHA256 string) error {
+	// Only the leaf (the server's own certificate) is checked: with chain
+	// validation skipped, any other certificate in the chain proves nothing.
+	if len(rawCerts) > 0 {
+		sum := sha256.Sum256(rawCerts[0])
+		if fmt.Sprintf("%x", sum) == pinnedSHA256 {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate does not match pinned fingerprint %q", pinnedSHA256)
+}
+
+// dohTransport implements DNS-over-HTTPS wire format (RFC 8484) using POST.
+type dohTransport struct {
+	httpClient *http.Client
+	url        string
+}
+
+func newDoHTransport(rawURL string, timeout time.Duration) *dohTransport {
+	return &dohTransport{
+		httpClient: &http.Client{Timeout: timeout},
+		url:        rawURL,
+	}
+}
+
+func (t *dohTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: unexpected status %d from %s", resp.StatusCode, t.url)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// exchangeGET performs the RFC 8484 GET variant, base64url-encoding the
+// packed query into the "dns" query parameter. Exchange always uses POST and
+// never calls this method; no Client option selects GET.
+func (t *dohTransport) exchangeGET(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: unexpected status %d from %s", resp.StatusCode, t.url)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// doqTransport implements DNS-over-QUIC (RFC 9250) on top of quic-go. Each
+// exchange opens a new bidirectional stream as required by the spec, writes
+// the length-prefixed query and reads the length-prefixed response.
+type doqTransport struct {
+	addr    string
+	timeout time.Duration
+}
+
+func newDoQTransport(addr string, timeout time.Duration) *doqTransport {
+	return &doqTransport{addr: addr, timeout: timeout}
+}
+
+func (t *doqTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return doqExchange(ctx, t.addr, t.timeout, msg)
+}
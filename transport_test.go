@@ -0,0 +1,47 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolverNoScheme(t *testing.T) {
+	pr, err := parseResolver("1.1.1.1")
+	require.NoError(t, err)
+	require.Equal(t, schemeUDP, pr.scheme)
+	require.Equal(t, "1.1.1.1:53", pr.addr)
+}
+
+func TestParseResolverNoSchemeWithPort(t *testing.T) {
+	pr, err := parseResolver("1.1.1.1:5353")
+	require.NoError(t, err)
+	require.Equal(t, schemeUDP, pr.scheme)
+	require.Equal(t, "1.1.1.1:5353", pr.addr)
+}
+
+func TestParseResolverTCP(t *testing.T) {
+	pr, err := parseResolver("tcp://1.1.1.1:53")
+	require.NoError(t, err)
+	require.Equal(t, schemeTCP, pr.scheme)
+	require.Equal(t, "1.1.1.1:53", pr.addr)
+}
+
+func TestParseResolverTLSDefaultPort(t *testing.T) {
+	pr, err := parseResolver("tls://1.1.1.1")
+	require.NoError(t, err)
+	require.Equal(t, schemeTLS, pr.scheme)
+	require.Equal(t, "1.1.1.1:853", pr.addr)
+}
+
+func TestParseResolverHTTPS(t *testing.T) {
+	pr, err := parseResolver("https://cloudflare-dns.com/dns-query")
+	require.NoError(t, err)
+	require.Equal(t, schemeHTTPS, pr.scheme)
+	require.Equal(t, "https://cloudflare-dns.com/dns-query", pr.addr)
+}
+
+func TestParseResolverUnsupportedScheme(t *testing.T) {
+	_, err := parseResolver("ftp://1.1.1.1")
+	require.Error(t, err)
+}
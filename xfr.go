@@ -0,0 +1,96 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGAuth holds the parameters needed to sign zone transfer requests with
+// TSIG (RFC 2845).
+type TSIGAuth struct {
+	Algorithm string // e.g. dns.HmacSHA256
+	KeyName   string
+	Secret    string // base64-encoded, as required by miekg/dns
+}
+
+// SetTSIG configures Client to sign AXFR/IXFR requests with auth. Pass a nil
+// auth to disable TSIG.
+func (c *Client) SetTSIG(auth *TSIGAuth) {
+	c.tsig = auth
+}
+
+// transfer issues req (an AXFR or IXFR question) against the next resolver
+// over TCP using dns.Transfer, and streams the resulting envelopes back on
+// the returned channel. The channel is closed once the transfer completes
+// or fails; a failing envelope is the last value sent before closing.
+func (c *Client) transfer(req *dns.Msg) (<-chan *dns.Envelope, error) {
+	resolver := c.nextResolver()
+
+	pr, err := parseResolver(resolver)
+	if err != nil {
+		return nil, err
+	}
+	if pr.scheme != schemeUDP && pr.scheme != schemeTCP {
+		return nil, fmt.Errorf("zone transfers require a plain udp/tcp resolver, got %q", resolver)
+	}
+
+	tr := &dns.Transfer{}
+	if c.tsig != nil {
+		req.SetTsig(dns.Fqdn(c.tsig.KeyName), c.tsig.Algorithm, 300, 0)
+		tr.TsigSecret = map[string]string{dns.Fqdn(c.tsig.KeyName): c.tsig.Secret}
+	}
+
+	envelopes, err := tr.In(req, pr.addr)
+	if err != nil {
+		return nil, err
+	}
+	return envelopes, nil
+}
+
+// AXFR requests a full zone transfer for zone from the next configured
+// resolver, returning a channel of envelopes as miekg/dns streams them in.
+// The resolver must be a plain udp:// or tcp:// entry; transfers always run
+// over TCP regardless.
+func (c *Client) AXFR(zone string) (<-chan *dns.Envelope, error) {
+	req := new(dns.Msg)
+	req.SetAxfr(dns.Fqdn(zone))
+	return c.transfer(req)
+}
+
+// IXFR requests an incremental zone transfer for zone starting from serial,
+// falling back to a full transfer if the server has no smaller diff to
+// offer. See AXFR for resolver requirements.
+func (c *Client) IXFR(zone string, serial uint32) (<-chan *dns.Envelope, error) {
+	req := new(dns.Msg)
+	req.SetIxfr(dns.Fqdn(zone), serial, "", "")
+	return c.transfer(req)
+}
+
+// SOASerial probes each configured resolver in turn for zone's SOA serial,
+// returning the first one that answers successfully.
+func (c *Client) SOASerial(zone string) (uint32, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeSOA)
+	msg.RecursionDesired = true
+
+	var err error
+	for i := 0; i < len(c.resolvers); i++ {
+		resolver := c.nextResolver()
+
+		var resp *dns.Msg
+		resp, err = c.exchangeOne(context.Background(), msg, resolver)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			if soa, ok := rr.(*dns.SOA); ok {
+				return soa.Serial, nil
+			}
+		}
+		err = fmt.Errorf("no SOA record returned for %q by %q", zone, resolver)
+	}
+
+	return 0, err
+}